@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/Sirupsen/logrus"
+)
+
+// uptimeLogFields builds the set of fields every host-checker log line
+// should carry, so operators can filter uptime activity in a log
+// aggregator by component/checker/host instead of regexing free-form
+// messages.
+func (hc *HostCheckerManager) uptimeLogFields(report HostHealthReport) logrus.Fields {
+	apiID := report.MetaData[UnHealthyHostMetaDataAPIKey]
+	orgID := ""
+	if thisSpec, found := ApiSpecRegister[apiID]; found {
+		orgID = thisSpec.OrgID
+	}
+
+	return logrus.Fields{
+		"component":     "host_checker",
+		"checker_id":    hc.Id,
+		"api_id":        apiID,
+		"org_id":        orgID,
+		"check_url":     report.CheckURL,
+		"host":          report.MetaData[UnHealthyHostMetaDataHostKey],
+		"latency_ms":    report.Latency,
+		"response_code": report.ResponseCode,
+	}
+}
+
+// uptimeLog returns a logrus.Entry pre-populated with uptimeLogFields, so
+// call sites can just do hc.uptimeLog(report).Warning("...").
+func (hc *HostCheckerManager) uptimeLog(report HostHealthReport) *logrus.Entry {
+	return log.WithFields(hc.uptimeLogFields(report))
+}
+
+// componentLog is for host-checker log lines that aren't about a specific
+// report (startup, ring membership, reload), but should still carry
+// component/checker_id so they can be filtered alongside per-report lines.
+func (hc *HostCheckerManager) componentLog() *logrus.Entry {
+	return log.WithFields(logrus.Fields{
+		"component":  "host_checker",
+		"checker_id": hc.Id,
+	})
+}
+
+// configureLogFormat honours the global log_format: json|text setting for
+// the shared logger, called once from Init.
+func configureLogFormat(format string) {
+	switch format {
+	case "json":
+		log.Formatter = &logrus.JSONFormatter{}
+	default:
+		log.Formatter = &logrus.TextFormatter{}
+	}
+}
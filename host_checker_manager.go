@@ -4,8 +4,9 @@ import (
 	b64 "encoding/base64"
 	"github.com/lonelycode/go-uuid/uuid"
 	"github.com/lonelycode/tykcommon"
-	"gopkg.in/vmihailenco/msgpack.v2"
+	"github.com/stathat/consistent"
 	"net/url"
+	"sync"
 	"time"
 )
 
@@ -18,8 +19,25 @@ type HostCheckerManager struct {
 	stopLoop          bool
 	pollerStarted     bool
 	unhealthyHostList map[string]bool
-	currentHostList   map[string]HostData
 	Clean             Purger
+	metrics           *HostCheckerMetrics
+
+	// ringMu guards ring, ringMembers and currentHostList: RingMembershipLoop
+	// reassigns ring/ringMembers and currentHostList from a background
+	// goroutine while StartPoller/ownedHostMap/ownsHost read them from
+	// others, so plain field access here is a data race.
+	ringMu          sync.RWMutex
+	ring            *consistent.Consistent
+	ringMembers     []string
+	currentHostList map[string]HostData
+
+	scoreMu        sync.RWMutex
+	scoreWindows   map[string][]HostHealthReport
+	scores         map[string]HostScoreBreakdown
+	scoreCallbacks []HostScoreCallback
+	wasHostUp      map[string]bool
+
+	sinks []UptimeAnalyticsSink
 }
 
 type UptimeReportData struct {
@@ -57,8 +75,12 @@ const (
 	UnHealthyHostMetaDataTargetKey string = "target_url"
 	UnHealthyHostMetaDataAPIKey    string = "api_id"
 	UnHealthyHostMetaDataHostKey   string = "host_name"
-	PollerCacheKey                 string = "PollerActiveInstanceID"
-	PoolerHostSentinelKeyPrefix    string = "PollerCheckerInstance:"
+	// PollerCacheKey is kept for backwards compatibility with older
+	// instances in a mixed-version cluster; it is no longer used to elect
+	// a single master, see host_checker_ring.go for the ring sharding.
+	PollerCacheKey              string = "PollerActiveInstanceID"
+	PoolerHostSentinelKeyPrefix string = "PollerCheckerInstance:"
+	EventDedupeKeyPrefix        string = "tyk-uptime-event-dedupe:"
 
 	UptimeAnalytics_KEYNAME string = "tyk-uptime-analytics"
 )
@@ -68,12 +90,28 @@ func (hc *HostCheckerManager) Init(store *RedisClusterStorageManager) {
 	hc.unhealthyHostList = make(map[string]bool)
 	// Generate a new ID for ourselves
 	hc.GenerateCheckerId()
+	hc.InitMetrics()
+	hc.initScoring()
+	hc.sinks = loadUptimeSinks(store, config.UptimeTests.Config.Sinks)
+	configureLogFormat(config.LogFormat)
 }
 
 func (hc *HostCheckerManager) Start() {
-	// Start loop to check if we are active instance
+	// Every instance now owns a slice of the host list (see
+	// host_checker_ring.go) instead of a single elected master running
+	// every check, so the poller starts unconditionally and the ring
+	// loop just keeps reshuffling which hosts it's given.
 	if hc.Id != "" {
-		go hc.CheckActivePollerLoop()
+		hc.renewRingMembership()
+
+		if !hc.pollerStarted {
+			hc.componentLog().WithField("event", "poller_start").Info("Starting poller")
+			hc.pollerStarted = true
+			go hc.StartPoller()
+		}
+
+		go hc.RingMembershipLoop()
+
 		if config.UptimeTests.Config.EnableUptimeAnalytics {
 			go hc.UptimePurgeLoop()
 		}
@@ -84,100 +122,73 @@ func (hc *HostCheckerManager) GenerateCheckerId() {
 	hc.Id = uuid.NewUUID().String()
 }
 
-func (hc *HostCheckerManager) CheckActivePollerLoop() {
-	for {
-		if hc.stopLoop {
-			log.Debug("[HOST CHECK MANAGER] Stopping uptime tests")
-			break
-		}
-
-		// If I'm polling, lets start the loop
-		if hc.AmIPolling() {
-			if !hc.pollerStarted {
-				log.Debug("[HOST CHECK MANAGER] Starting Poller")
-				hc.pollerStarted = true
-				go hc.StartPoller()
-			}
-		} else {
-			log.Debug("[HOST CHECK MANAGER] New master found, stopping uptime tests")
-			if hc.pollerStarted {
-				go hc.StopPoller()
-				hc.pollerStarted = false
-			}
-		}
-
-		time.Sleep(10 * time.Second)
-	}
-}
-
 func (hc *HostCheckerManager) UptimePurgeLoop() {
 	if config.AnalyticsConfig.PurgeDelay == -1 {
-		log.Warning("Analytics purge turned off, you are responsible for Redis storage maintenance.")
+		hc.componentLog().Warning("Analytics purge turned off, you are responsible for Redis storage maintenance.")
 		return
 	}
-	log.Debug("[HOST CHECK MANAGER] Started analytics purge loop")
+	hc.componentLog().Debug("Started analytics purge loop")
 	for {
 		if hc.pollerStarted {
 			if hc.Clean != nil {
-				log.Debug("[HOST CHECK MANAGER] Purging uptime analytics")
+				hc.componentLog().Debug("Purging uptime analytics")
 				hc.Clean.PurgeCache()
 			}
 
+			// Piggyback the sink flush on the same cadence as the Redis
+			// purge so the non-Redis sinks (which batch up to
+			// sinkFlushInterval/sinkBatchSize on their own) also get
+			// flushed on a predictable schedule rather than only when
+			// their internal ticker happens to fire.
+			for _, sink := range hc.sinks {
+				sink.Flush()
+			}
 		}
 		time.Sleep(time.Duration(config.AnalyticsConfig.PurgeDelay) * time.Second)
 	}
 }
 
-func (hc *HostCheckerManager) AmIPolling() bool {
-	if hc.store == nil {
-		log.Error("[HOST CHECK MANAGER] No storage instance set for uptime tests! Disabling poller...")
-		return false
+// Stop tears down the host checker: it stops the ring membership/poller
+// loops and flushes then closes every configured uptime analytics sink, so
+// no batched records are lost and no sink goroutine is left running. The
+// gateway's shutdown/signal-handling path (outside this snapshot) should
+// call this before process exit.
+func (hc *HostCheckerManager) Stop() {
+	hc.stopLoop = true
+	hc.StopPoller()
+
+	for _, sink := range hc.sinks {
+		sink.Flush()
+		sink.Close()
 	}
-	ActiveInstance, err := hc.store.GetKey(PollerCacheKey)
-	if err != nil {
-		log.Debug("[HOST CHECK MANAGER] No Primary instance found, assuming control")
-		hc.store.SetKey(PollerCacheKey, hc.Id, 15)
-		return true
-	}
-
-	if ActiveInstance == hc.Id {
-		log.Debug("[HOST CHECK MANAGER] Primary instance set, I am master")
-		hc.store.SetKey(PollerCacheKey, hc.Id, 15) // Reset TTL
-		return true
-	}
-
-	log.Debug("Active Instance is: ", ActiveInstance)
-	log.Debug("--- I am: ", hc.Id)
-
-	return false
 }
 
 func (hc *HostCheckerManager) StartPoller() {
-
-	log.Debug("---> Initialising checker")
+	hc.componentLog().Debug("Initialising checker")
 
 	// If we are restarting, we want to retain the host list
 	if hc.checker == nil {
 		hc.checker = &HostUptimeChecker{}
 	}
 
+	owned := hc.ownedHostMap()
 	hc.checker.Init(config.UptimeTests.Config.CheckerPoolSize,
 		config.UptimeTests.Config.FailureTriggerSampleSize,
 		config.UptimeTests.Config.TimeWait,
-		hc.currentHostList,
+		owned,
 		hc.OnHostDown,   // On failure
 		hc.OnHostBackUp, // On success
 		hc.OnHostReport) // All reports
 
 	// Start the check loop
-	log.Debug("---> Starting checker")
 	hc.checker.Start()
-	log.Debug("---> Checker started.")
+	hc.componentLog().WithField("event", "poller_started").WithField("owned_hosts", len(owned)).Info("Checker started")
 }
 
 func (hc *HostCheckerManager) StopPoller() {
 	if hc.checker != nil {
 		hc.checker.Stop()
+		hc.componentLog().WithField("event", "poller_stopped").Info("Checker stopped")
 	}
 }
 
@@ -185,47 +196,114 @@ func (hc *HostCheckerManager) getHostKey(report HostHealthReport) string {
 	return PoolerHostSentinelKeyPrefix + report.MetaData[UnHealthyHostMetaDataHostKey]
 }
 
+// shouldFireEvent de-duplicates reports across the RingReplicas owners of a
+// host: since a host is now polled by more than one instance for
+// redundancy, only the first instance to observe a given state transition
+// should fire the webhook/event, not every owner.
+func (hc *HostCheckerManager) shouldFireEvent(report HostHealthReport, state string) bool {
+	dedupeKey := EventDedupeKeyPrefix + state + ":" + hc.getHostKey(report)
+	if _, err := hc.store.GetKey(dedupeKey); err == nil {
+		return false
+	}
+
+	hc.store.SetKey(dedupeKey, hc.Id, int64(config.UptimeTests.Config.TimeWait))
+	return true
+}
+
 func (hc *HostCheckerManager) OnHostReport(report HostHealthReport) {
+	hc.onHostReportMetrics(report)
+	hc.recordScore(report)
 	if config.UptimeTests.Config.EnableUptimeAnalytics {
 		go hc.RecordUptimeAnalytics(report)
 	}
 }
 
+// isMarkedUp reports the last known up/down state for checkURL, defaulting
+// to "up" for a host we haven't scored a transition for yet.
+func (hc *HostCheckerManager) isMarkedUp(checkURL string) bool {
+	up, found := hc.wasHostUp[checkURL]
+	if !found {
+		return true
+	}
+	return up
+}
+
 func (hc *HostCheckerManager) OnHostDown(report HostHealthReport) {
-	log.Debug("Update key: ", hc.getHostKey(report))
+	hc.onHostStateMetrics(report, false)
+	hc.uptimeLog(report).WithField("redis_key", hc.getHostKey(report)).Debug("Marking host down")
 	hc.store.SetKey(hc.getHostKey(report), "1", int64(config.UptimeTests.Config.TimeWait))
 
+	// OnHostReport already ran for this sample and recorded it into the
+	// rolling window; read the breakdown it computed rather than
+	// recording the sample a second time.
+	breakdown := hc.currentScore(report.CheckURL)
+
+	hc.scoreMu.Lock()
+	transitioned := hc.isMarkedUp(report.CheckURL) && breakdown.Composite < HostDownThreshold
+	if transitioned {
+		hc.wasHostUp[report.CheckURL] = false
+	}
+	hc.scoreMu.Unlock()
+
+	if !transitioned || !hc.shouldFireEvent(report, "down") {
+		return
+	}
+
 	thisSpec, found := ApiSpecRegister[report.MetaData[UnHealthyHostMetaDataAPIKey]]
 	if !found {
-		log.Warning("[HOST CHECKER MANAGER] Event can't fire for API that doesn't exist")
+		hc.uptimeLog(report).Warning("Event can't fire for API that doesn't exist")
 		return
 	}
 
 	go thisSpec.FireEvent(EVENT_HOSTDOWN,
-		EVENT_HostStatusMeta{
-			EventMetaDefault: EventMetaDefault{Message: "Uptime test failed"},
-			HostInfo:         report,
+		EVENT_HostScoreMeta{
+			EVENT_HostStatusMeta: EVENT_HostStatusMeta{
+				EventMetaDefault: EventMetaDefault{Message: "Uptime test failed"},
+				HostInfo:         report,
+			},
+			Score:     breakdown,
+			Diagnosis: diagnose(report, breakdown),
 		})
 
-	log.Warning("[HOST CHECKER MANAGER] Host is DOWN: ", report.CheckURL)
+	hc.uptimeLog(report).WithField("event", "host_down").Warning("Host is down")
 }
 
 func (hc *HostCheckerManager) OnHostBackUp(report HostHealthReport) {
-	log.Debug("Delete key: ", hc.getHostKey(report))
+	hc.onHostStateMetrics(report, true)
+	hc.uptimeLog(report).WithField("redis_key", hc.getHostKey(report)).Debug("Marking host up")
 	hc.store.DeleteKey(hc.getHostKey(report))
 
+	// See the comment in OnHostDown: OnHostReport already recorded this
+	// sample, so just read the breakdown instead of recording it again.
+	breakdown := hc.currentScore(report.CheckURL)
+
+	hc.scoreMu.Lock()
+	transitioned := !hc.isMarkedUp(report.CheckURL) && breakdown.Composite > HostUpThreshold
+	if transitioned {
+		hc.wasHostUp[report.CheckURL] = true
+	}
+	hc.scoreMu.Unlock()
+
+	if !transitioned || !hc.shouldFireEvent(report, "up") {
+		return
+	}
+
 	thisSpec, found := ApiSpecRegister[report.MetaData[UnHealthyHostMetaDataAPIKey]]
 	if !found {
-		log.Warning("[HOST CHECKER MANAGER] Event can't fire for API that doesn't exist")
+		hc.uptimeLog(report).Warning("Event can't fire for API that doesn't exist")
 		return
 	}
 	go thisSpec.FireEvent(EVENT_HOSTUP,
-		EVENT_HostStatusMeta{
-			EventMetaDefault: EventMetaDefault{Message: "Uptime test suceeded"},
-			HostInfo:         report,
+		EVENT_HostScoreMeta{
+			EVENT_HostStatusMeta: EVENT_HostStatusMeta{
+				EventMetaDefault: EventMetaDefault{Message: "Uptime test suceeded"},
+				HostInfo:         report,
+			},
+			Score:     breakdown,
+			Diagnosis: diagnose(report, breakdown),
 		})
 
-	log.Warning("[HOST CHECKER MANAGER] Host is UP:   ", report.CheckURL)
+	hc.uptimeLog(report).WithField("event", "host_up").Warning("Host is up")
 }
 
 func (hc *HostCheckerManager) IsHostDown(thisUrl string) bool {
@@ -234,7 +312,7 @@ func (hc *HostCheckerManager) IsHostDown(thisUrl string) bool {
 		log.Error(err)
 	}
 
-	log.Debug("Key is: ", PoolerHostSentinelKeyPrefix+u.Host)
+	hc.componentLog().WithField("redis_key", PoolerHostSentinelKeyPrefix+u.Host).Debug("Checking host down key")
 	_, fErr := hc.store.GetKey(PoolerHostSentinelKeyPrefix + u.Host)
 
 	if fErr != nil {
@@ -285,20 +363,61 @@ func (hc *HostCheckerManager) PrepareTrackingHost(checkObject tykcommon.HostChec
 
 func (hc *HostCheckerManager) UpdateTrackingList(hd []HostData) {
 	log.Debug("--- Setting tracking list up")
-	newHostList := make(map[string]HostData)
+	hc.applyOwnedHostList(hd)
+}
+
+// applyOwnedHostList keeps the full host list (so ring recomputation always
+// has the complete set to redistribute) but only ever hands the local
+// checker the subset this instance owns on the ring, draining any host that
+// has moved to another owner in the process.
+func (hc *HostCheckerManager) applyOwnedHostList(hd []HostData) {
+	fullHostList := make(map[string]HostData)
 	for _, host := range hd {
-		newHostList[host.CheckURL] = host
+		fullHostList[host.CheckURL] = host
 	}
 
-	hc.currentHostList = newHostList
+	hc.ringMu.Lock()
+	hc.currentHostList = fullHostList
+	hc.ringMu.Unlock()
+
+	owned := hc.ownedHostMap()
 	if hc.checker != nil {
-		log.Debug("Reset initiated")
-		hc.checker.ResetList(&newHostList)
+		log.Debug("Reset initiated, owned hosts: ", len(owned), " of ", len(fullHostList))
+		hc.checker.ResetList(&owned)
+	}
+}
+
+// currentHostURLSet returns the set of CheckURLs currently tracked, for
+// callers (e.g. the reload watcher) that need to diff the host list without
+// reaching into currentHostList directly.
+func (hc *HostCheckerManager) currentHostURLSet() map[string]bool {
+	hc.ringMu.RLock()
+	defer hc.ringMu.RUnlock()
+
+	set := make(map[string]bool, len(hc.currentHostList))
+	for url := range hc.currentHostList {
+		set[url] = true
 	}
+	return set
+}
+
+// ownedHostMap filters the full tracked host list down to the hosts this
+// instance owns on the consistent-hash ring (see host_checker_ring.go).
+func (hc *HostCheckerManager) ownedHostMap() map[string]HostData {
+	hc.ringMu.RLock()
+	defer hc.ringMu.RUnlock()
+
+	owned := make(map[string]HostData)
+	for url, host := range hc.currentHostList {
+		if hc.ownsHost(url) {
+			owned[url] = host
+		}
+	}
+	return owned
 }
 
 // RecordHit will store an AnalyticsRecord in Redis
-func (hc HostCheckerManager) RecordUptimeAnalytics(thisReport HostHealthReport) error {
+func (hc *HostCheckerManager) RecordUptimeAnalytics(thisReport HostHealthReport) error {
 	// If we are obfuscating API Keys, store the hashed representation (config check handled in hashing function)
 
 	thisSpec, found := ApiSpecRegister[thisReport.MetaData[UnHealthyHostMetaDataAPIKey]]
@@ -330,14 +449,12 @@ func (hc HostCheckerManager) RecordUptimeAnalytics(thisReport HostHealthReport)
 
 	newAnalyticsRecord.SetExpiry(thisSpec.UptimeTests.Config.ExpireUptimeAnalyticsAfter)
 
-	encoded, err := msgpack.Marshal(newAnalyticsRecord)
-
-	if err != nil {
-		log.Error("Error encoding uptime data:", err)
-		return err
+	for _, sink := range hc.sinks {
+		if err := sink.Write(newAnalyticsRecord); err != nil {
+			log.Error("Error writing uptime data to sink:", err)
+		}
 	}
 
-	hc.store.AppendToSet(UptimeAnalytics_KEYNAME, string(encoded))
 	return nil
 }
 
@@ -346,6 +463,27 @@ func InitHostCheckManager(store *RedisClusterStorageManager, purger Purger) {
 	GlobalHostChecker.Clean = purger
 	GlobalHostChecker.Init(store)
 	GlobalHostChecker.Start()
+
+	// doReload is the gateway's existing config/API-definition reload
+	// entrypoint (reload.go); wiring it here means a filesystem change
+	// under CheckListWatchDir actually picks up new/changed API
+	// definitions instead of only re-scanning whatever was already in
+	// ApiSpecRegister. Only default it if nothing's claimed the hook yet,
+	// so a caller that wants different reload behaviour can still set
+	// ReloadAPISpecs itself before calling InitHostCheckManager.
+	if ReloadAPISpecs == nil {
+		ReloadAPISpecs = func() error {
+			doReload()
+			return nil
+		}
+	}
+
+	watcher, err := StartUptimeWatcher(config.UptimeTests.Config.CheckListWatchDir)
+	if err != nil {
+		GlobalHostChecker.componentLog().Error("Could not start uptime check list watcher: ", err)
+	} else {
+		GlobalReloadWatcher = watcher
+	}
 }
 
 func SetCheckerHostList() {
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HostCheckerMetrics holds the Prometheus collectors that mirror the uptime
+// data HostCheckerManager already writes to UptimeAnalytics_KEYNAME, so
+// operators can scrape live uptime state instead of only reading it back out
+// of the msgpack blob in Redis.
+type HostCheckerMetrics struct {
+	enabled          bool
+	stripHighCard    bool
+	hostUp           *prometheus.GaugeVec
+	probeTotal       *prometheus.CounterVec
+	tcpErrorTotal    *prometheus.CounterVec
+	statusClassTotal *prometheus.CounterVec
+	latency          *prometheus.HistogramVec
+}
+
+// uptimeMetricLabels returns the label set for a report, stripping the
+// high-cardinality check_url label when configured to do so.
+func (m *HostCheckerMetrics) uptimeMetricLabels(report HostHealthReport) prometheus.Labels {
+	apiID := report.MetaData[UnHealthyHostMetaDataAPIKey]
+	orgID := ""
+	if thisSpec, found := ApiSpecRegister[apiID]; found {
+		orgID = thisSpec.OrgID
+	}
+
+	checkURL := report.CheckURL
+	if m.stripHighCard {
+		checkURL = ""
+	}
+
+	return prometheus.Labels{
+		"check_url": checkURL,
+		"api_id":    apiID,
+		"org_id":    orgID,
+	}
+}
+
+// hostCheckerMetricLabels are the label names shared by every uptime
+// collector.
+var hostCheckerMetricLabels = []string{"check_url", "api_id", "org_id"}
+
+// hostCheckerMetrics are the uptime collectors themselves, built and
+// registered exactly once at package init (the same pattern
+// sinkBackpressureDropped/sinkQueueDepth use in uptime_sinks.go), so
+// InitMetrics can be called more than once — e.g. from a config reload or a
+// test harness — without prometheus.MustRegister panicking on a duplicate
+// collector.
+var hostCheckerMetrics = newHostCheckerMetrics()
+
+func newHostCheckerMetrics() *HostCheckerMetrics {
+	labels := hostCheckerMetricLabels
+
+	return &HostCheckerMetrics{
+		hostUp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tyk_uptime_host_up",
+			Help: "1 if the last uptime probe for this host succeeded, 0 otherwise.",
+		}, labels),
+		probeTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tyk_uptime_probes_total",
+			Help: "Total number of uptime probes performed for this host.",
+		}, labels),
+		tcpErrorTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tyk_uptime_tcp_errors_total",
+			Help: "Total number of TCP-level errors seen while probing this host.",
+		}, labels),
+		statusClassTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tyk_uptime_status_class_total",
+			Help: "Total number of probes by HTTP status class (2xx, 4xx, 5xx, ...).",
+		}, append(append([]string{}, labels...), "status_class")),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tyk_uptime_probe_latency_ms",
+			Help:    "Latency of uptime probes in milliseconds.",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 16),
+		}, labels),
+	}
+}
+
+func init() {
+	prometheus.MustRegister(
+		hostCheckerMetrics.hostUp,
+		hostCheckerMetrics.probeTotal,
+		hostCheckerMetrics.tcpErrorTotal,
+		hostCheckerMetrics.statusClassTotal,
+		hostCheckerMetrics.latency,
+	)
+}
+
+// InitMetrics points hc.metrics at the package's singleton collector set and
+// starts the exporter. It's safe to call more than once per process: the
+// collectors themselves are only ever registered once, in this file's
+// init().
+func (hc *HostCheckerManager) InitMetrics() {
+	if !config.UptimeTests.Config.EnablePrometheusMetrics {
+		return
+	}
+
+	m := hostCheckerMetrics
+	m.enabled = true
+	m.stripHighCard = config.UptimeTests.Config.PrometheusStripHighCardinality
+	hc.metrics = m
+
+	go hc.serveMetrics()
+}
+
+func (hc *HostCheckerManager) serveMetrics() {
+	addr := config.UptimeTests.Config.PrometheusListenAddress
+	if addr == "" {
+		addr = ":9191"
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics/uptime", prometheus.Handler())
+
+	srv := &http.Server{Addr: addr, Handler: mux}
+
+	if !config.UptimeTests.Config.PrometheusUseMutualTLS {
+		hc.componentLog().Error("Prometheus uptime exporter failed: ", srv.ListenAndServe())
+		return
+	}
+
+	tlsConfig, err := hc.buildMetricsTLSConfig()
+	if err != nil {
+		hc.componentLog().Error("Could not start mTLS uptime exporter: ", err)
+		return
+	}
+	srv.TLSConfig = tlsConfig
+	// The cert/key are already loaded into srv.TLSConfig.Certificates, so
+	// pass empty paths here; ListenAndServe (not TLS) would ignore
+	// TLSConfig entirely and serve the exporter in plaintext.
+	hc.componentLog().Error("Prometheus uptime exporter failed: ", srv.ListenAndServeTLS("", ""))
+}
+
+func (hc *HostCheckerManager) buildMetricsTLSConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(
+		config.UptimeTests.Config.PrometheusServerCertFile,
+		config.UptimeTests.Config.PrometheusServerKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := loadCertPool(config.UptimeTests.Config.PrometheusClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}, nil
+}
+
+// onHostReportMetrics updates the counters/histogram that fire on every
+// probe, regardless of outcome.
+func (hc *HostCheckerManager) onHostReportMetrics(report HostHealthReport) {
+	if hc.metrics == nil || !hc.metrics.enabled {
+		return
+	}
+
+	labels := hc.metrics.uptimeMetricLabels(report)
+	hc.metrics.probeTotal.With(labels).Inc()
+	hc.metrics.latency.With(labels).Observe(float64(report.Latency))
+
+	if report.IsTCPError {
+		hc.metrics.tcpErrorTotal.With(labels).Inc()
+	}
+
+	classLabels := prometheus.Labels{}
+	for k, v := range labels {
+		classLabels[k] = v
+	}
+	classLabels["status_class"] = statusClassOf(report.ResponseCode)
+	hc.metrics.statusClassTotal.With(classLabels).Inc()
+}
+
+// onHostStateMetrics updates the up/down gauge, called from OnHostDown and
+// OnHostBackUp so the gauge always reflects the last known state.
+func (hc *HostCheckerManager) onHostStateMetrics(report HostHealthReport, up bool) {
+	if hc.metrics == nil || !hc.metrics.enabled {
+		return
+	}
+
+	val := 0.0
+	if up {
+		val = 1.0
+	}
+	hc.metrics.hostUp.With(hc.metrics.uptimeMetricLabels(report)).Set(val)
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	caCert, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caCert)
+	return pool, nil
+}
+
+func statusClassOf(code int) string {
+	switch {
+	case code >= 200 && code < 300:
+		return "2xx"
+	case code >= 300 && code < 400:
+		return "3xx"
+	case code >= 400 && code < 500:
+		return "4xx"
+	case code >= 500:
+		return "5xx"
+	default:
+		return "unknown"
+	}
+}
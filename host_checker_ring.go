@@ -0,0 +1,160 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/stathat/consistent"
+)
+
+const (
+	// RingMemberKeyPrefix namespaces the per-instance heartbeat keys that
+	// make up the uptime polling ring membership set.
+	RingMemberKeyPrefix string = "tyk-uptime-ring:"
+
+	// RingMemberTTL is how long a heartbeat key lives before it expires,
+	// dropping that instance out of the ring if it stops renewing.
+	RingMemberTTL int64 = 15
+
+	// RingHeartbeatInterval is how often each instance renews its
+	// membership and recomputes ring ownership.
+	RingHeartbeatInterval = 5 * time.Second
+
+	// RingVirtualNodes is consistent.Consistent's NumberOfReplicas: how
+	// many points each member gets hashed to around the ring for load
+	// balancing. This is unrelated to check redundancy — it just needs to
+	// be high enough to spread hosts evenly across instances.
+	RingVirtualNodes = 20
+
+	// RingReplicas is how many distinct owners GetN resolves per host, so
+	// that RingReplicas-1 other instances also own a copy of the check as
+	// a redundancy overlap.
+	RingReplicas = 2
+)
+
+// RingMembershipLoop replaces the old single-master CheckActivePollerLoop:
+// every instance renews its own heartbeat, watches for ring membership
+// changes, and recomputes which slice of hosts it owns rather than
+// electing a single instance to run every check.
+func (hc *HostCheckerManager) RingMembershipLoop() {
+	for {
+		if hc.stopLoop {
+			hc.componentLog().WithField("event", "ring_loop_stopped").Debug("Stopping ring membership loop")
+			return
+		}
+
+		changed := hc.renewRingMembership()
+		if changed {
+			hc.componentLog().
+				WithField("event", "ring_membership_changed").
+				WithField("members", len(hc.ringMembers)).
+				Info("Ring membership changed, recomputing host ownership")
+			hc.recomputeOwnedHosts()
+		}
+
+		time.Sleep(RingHeartbeatInterval)
+	}
+}
+
+// renewRingMembership writes this instance's heartbeat and rebuilds the
+// local ring from the currently live member set. It returns true if the
+// member set differs from the previous build, so callers only need to
+// redistribute work when ownership could actually have moved.
+func (hc *HostCheckerManager) renewRingMembership() bool {
+	if hc.store == nil {
+		hc.componentLog().Error("No storage instance set for uptime tests! Disabling ring...")
+		return false
+	}
+
+	hc.store.SetKey(RingMemberKeyPrefix+hc.Id, strconv.FormatInt(time.Now().Unix(), 10), RingMemberTTL)
+
+	members := hc.liveRingMembers()
+	sort.Strings(members)
+
+	hc.ringMu.RLock()
+	unchanged := hc.ringMembers != nil && sameMembers(hc.ringMembers, members)
+	hc.ringMu.RUnlock()
+	if unchanged {
+		return false
+	}
+
+	ring := consistent.New()
+	ring.NumberOfReplicas = RingVirtualNodes
+	for _, m := range members {
+		ring.Add(m)
+	}
+
+	hc.ringMu.Lock()
+	hc.ring = ring
+	hc.ringMembers = members
+	hc.ringMu.Unlock()
+	return true
+}
+
+// liveRingMembers lists the instance IDs that currently hold a live
+// heartbeat key, stripping the key prefix back down to the bare Id.
+func (hc *HostCheckerManager) liveRingMembers() []string {
+	keys := hc.store.GetKeys(RingMemberKeyPrefix + "*")
+	members := make([]string, 0, len(keys))
+	for _, k := range keys {
+		members = append(members, k[len(RingMemberKeyPrefix):])
+	}
+	return members
+}
+
+func sameMembers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ownsHost reports whether this instance is one of the RingReplicas owners
+// of checkURL, so UpdateTrackingList can hand the checker only the slice of
+// hosts this instance is responsible for. Callers must hold hc.ringMu (at
+// least for reading) before calling this, since it reads hc.ring directly.
+func (hc *HostCheckerManager) ownsHost(checkURL string) bool {
+	if hc.ring == nil {
+		// No ring built yet (e.g. storage unavailable): fall back to
+		// owning everything so checks still run rather than silently
+		// dropping to zero coverage.
+		return true
+	}
+
+	owners, err := hc.ring.GetN(checkURL, RingReplicas)
+	if err != nil {
+		hc.componentLog().WithField("check_url", checkURL).Warning("Could not resolve ring owners: ", err)
+		return true
+	}
+
+	for _, owner := range owners {
+		if owner == hc.Id {
+			return true
+		}
+	}
+	return false
+}
+
+// recomputeOwnedHosts re-applies the current ring to the last full host
+// list we were given, draining any hosts that moved to another owner and
+// picking up any that moved to us.
+func (hc *HostCheckerManager) recomputeOwnedHosts() {
+	hc.ringMu.RLock()
+	if hc.currentHostList == nil {
+		hc.ringMu.RUnlock()
+		return
+	}
+	full := make([]HostData, 0, len(hc.currentHostList))
+	for _, h := range hc.currentHostList {
+		full = append(full, h)
+	}
+	hc.ringMu.RUnlock()
+
+	hc.applyOwnedHostList(full)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stathat/consistent"
+)
+
+// buildTestRing mirrors what renewRingMembership would have built, without
+// needing a real RedisClusterStorageManager to source the member list from.
+func buildTestRing(members ...string) *consistent.Consistent {
+	ring := consistent.New()
+	ring.NumberOfReplicas = RingVirtualNodes
+	for _, m := range members {
+		ring.Add(m)
+	}
+	return ring
+}
+
+func TestOwnsHostDistributesAcrossMembers(t *testing.T) {
+	members := []string{"instance-a", "instance-b", "instance-c"}
+	ring := buildTestRing(members...)
+
+	owners := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		checkURL := fmt.Sprintf("http://host-%d.example.com", i)
+		got, err := ring.GetN(checkURL, RingReplicas)
+		if err != nil {
+			t.Fatalf("GetN returned an error: %v", err)
+		}
+		if len(got) != RingReplicas {
+			t.Fatalf("expected %d owners, got %d", RingReplicas, len(got))
+		}
+		for _, owner := range got {
+			owners[owner]++
+		}
+	}
+
+	if len(owners) != len(members) {
+		t.Fatalf("expected all %d members to own at least one host, only %d did: %v", len(members), len(owners), owners)
+	}
+}
+
+func TestOwnsHostAgreesWithRingGetN(t *testing.T) {
+	hc := &HostCheckerManager{}
+	hc.ring = buildTestRing("instance-a", "instance-b", "instance-c")
+	hc.Id = "instance-b"
+
+	owned := 0
+	for i := 0; i < 100; i++ {
+		checkURL := fmt.Sprintf("http://host-%d.example.com", i)
+		owners, err := hc.ring.GetN(checkURL, RingReplicas)
+		if err != nil {
+			t.Fatalf("GetN returned an error: %v", err)
+		}
+
+		want := false
+		for _, owner := range owners {
+			if owner == hc.Id {
+				want = true
+			}
+		}
+		if got := hc.ownsHost(checkURL); got != want {
+			t.Fatalf("ownsHost(%q) = %v, want %v (ring owners: %v)", checkURL, got, want, owners)
+		}
+		if want {
+			owned++
+		}
+	}
+
+	if owned == 0 || owned == 100 {
+		t.Fatalf("expected a partial share of hosts to land on instance-b, got %d/100", owned)
+	}
+}
+
+func TestOwnsHostWithNoRingOwnsEverything(t *testing.T) {
+	hc := &HostCheckerManager{}
+	if !hc.ownsHost("http://any.example.com") {
+		t.Fatal("expected ownsHost to fall back to true when no ring has been built yet")
+	}
+}
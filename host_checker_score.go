@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// UnHealthyHostMetaDataTLSExpiryKey carries the number of days left on
+	// a host's TLS certificate, when the checker was able to determine it,
+	// following the existing convention of threading extra per-probe data
+	// through HostHealthReport.MetaData rather than growing that struct.
+	UnHealthyHostMetaDataTLSExpiryKey string = "tls_expiry_days"
+
+	// ScoreWindowSize is how many recent HostHealthReports are kept per
+	// CheckURL to compute a rolling HostScoreBreakdown from.
+	ScoreWindowSize = 20
+
+	// LatencySLOMilliseconds is the mean latency at which LatencyScore
+	// reaches 0; LatencyScore falls linearly from 1.0 at zero latency.
+	LatencySLOMilliseconds = 1000.0
+
+	// TLSExpiryWarnDays is how many days out an expiring certificate
+	// starts dragging TLSExpiryScore down.
+	TLSExpiryWarnDays = 14.0
+
+	// HostDownThreshold/HostUpThreshold give the composite score
+	// hysteresis band: a host must drop below HostDownThreshold to be
+	// marked down, and climb back above HostUpThreshold to be marked up
+	// again, so a score oscillating around a single cutoff doesn't spam
+	// events.
+	HostDownThreshold = 0.4
+	HostUpThreshold   = 0.7
+)
+
+// HostScoreBreakdown is a 0-1 normalised scoring of a host's recent probe
+// history, aggregated into Composite. It is computed from a rolling window
+// of HostHealthReports for a given CheckURL.
+type HostScoreBreakdown struct {
+	CheckURL            string
+	LatencyScore        float64
+	AvailabilityScore   float64
+	JitterScore         float64
+	TLSExpiryScore      float64
+	StatusCodeScore     float64
+	ConsecutiveFailures int
+	Composite           float64
+}
+
+// HostGougingBreakdown enumerates *why* a host is considered unusable, so
+// events carry a structured diagnosis instead of a single free-form
+// "Uptime test failed" message.
+type HostGougingBreakdown struct {
+	TCPErr        bool
+	TLSErr        bool
+	LatencySLOErr bool
+	StatusErr     bool
+}
+
+// EVENT_HostScoreMeta extends EVENT_HostStatusMeta with the score and
+// gouging breakdown that drove the event, so webhook consumers can see the
+// full diagnosis rather than just the up/down transition.
+type EVENT_HostScoreMeta struct {
+	EVENT_HostStatusMeta
+	Score     HostScoreBreakdown
+	Diagnosis HostGougingBreakdown
+}
+
+// HostScoreCallback is invoked every time a host's score is recomputed,
+// alongside the existing OnHostReport/OnHostDown/OnHostBackUp slots.
+type HostScoreCallback func(HostScoreBreakdown)
+
+func (hc *HostCheckerManager) initScoring() {
+	hc.scoreWindows = make(map[string][]HostHealthReport)
+	hc.scores = make(map[string]HostScoreBreakdown)
+	hc.wasHostUp = make(map[string]bool)
+}
+
+// RegisterScoreCallback adds a callback invoked whenever a host's
+// HostScoreBreakdown is recomputed, alongside OnHostReport.
+func (hc *HostCheckerManager) RegisterScoreCallback(cb HostScoreCallback) {
+	hc.scoreMu.Lock()
+	defer hc.scoreMu.Unlock()
+	hc.scoreCallbacks = append(hc.scoreCallbacks, cb)
+}
+
+// recordScore pushes report into the rolling window for its CheckURL,
+// recomputes the HostScoreBreakdown and returns it.
+func (hc *HostCheckerManager) recordScore(report HostHealthReport) HostScoreBreakdown {
+	hc.scoreMu.Lock()
+	defer hc.scoreMu.Unlock()
+
+	window := append(hc.scoreWindows[report.CheckURL], report)
+	if len(window) > ScoreWindowSize {
+		window = window[len(window)-ScoreWindowSize:]
+	}
+	hc.scoreWindows[report.CheckURL] = window
+
+	breakdown := scoreWindow(report.CheckURL, window)
+	hc.scores[report.CheckURL] = breakdown
+
+	for _, cb := range hc.scoreCallbacks {
+		go cb(breakdown)
+	}
+
+	return breakdown
+}
+
+func scoreWindow(checkURL string, window []HostHealthReport) HostScoreBreakdown {
+	n := float64(len(window))
+	if n == 0 {
+		return HostScoreBreakdown{CheckURL: checkURL, Composite: 1}
+	}
+
+	var successes, latencySum, latencySumSq, statusPenalty float64
+	consecutiveFailures := 0
+	tlsScore := 1.0
+
+	for _, r := range window {
+		ok := !r.IsTCPError && r.ResponseCode > 0 && r.ResponseCode < 500
+		if ok {
+			successes++
+			consecutiveFailures = 0
+			latencySum += float64(r.Latency)
+			latencySumSq += float64(r.Latency) * float64(r.Latency)
+			if r.ResponseCode >= 400 {
+				statusPenalty++
+			}
+		} else {
+			// An unreachable probe (TCP error, no response, 5xx) has no
+			// real round-trip to measure, so treating its zero-value
+			// Latency as "fast" would make a dead host look healthy on
+			// every component but AvailabilityScore. Score it as an
+			// outright SLO breach instead, so LatencyScore and
+			// StatusCodeScore drop along with availability (JitterScore
+			// drops only when failures are mixed with successes).
+			consecutiveFailures++
+			statusPenalty++
+			failureLatency := 2 * LatencySLOMilliseconds
+			latencySum += failureLatency
+			latencySumSq += failureLatency * failureLatency
+		}
+
+		if days, err := strconv.ParseFloat(r.MetaData[UnHealthyHostMetaDataTLSExpiryKey], 64); err == nil {
+			if s := tlsExpiryScore(days); s < tlsScore {
+				tlsScore = s
+			}
+		}
+	}
+
+	meanLatency := latencySum / n
+	variance := (latencySumSq / n) - (meanLatency * meanLatency)
+	if variance < 0 {
+		variance = 0
+	}
+	jitter := math.Sqrt(variance)
+
+	breakdown := HostScoreBreakdown{
+		CheckURL:            checkURL,
+		AvailabilityScore:   successes / n,
+		LatencyScore:        clamp01(1 - (meanLatency / LatencySLOMilliseconds)),
+		JitterScore:         clamp01(1 - (jitter / LatencySLOMilliseconds)),
+		TLSExpiryScore:      tlsScore,
+		StatusCodeScore:     clamp01(1 - (statusPenalty / n)),
+		ConsecutiveFailures: consecutiveFailures,
+	}
+
+	breakdown.Composite = clamp01(
+		0.35*breakdown.AvailabilityScore +
+			0.25*breakdown.LatencyScore +
+			0.15*breakdown.JitterScore +
+			0.15*breakdown.StatusCodeScore +
+			0.10*breakdown.TLSExpiryScore,
+	)
+
+	return breakdown
+}
+
+func tlsExpiryScore(daysLeft float64) float64 {
+	if daysLeft >= TLSExpiryWarnDays {
+		return 1
+	}
+	return clamp01(daysLeft / TLSExpiryWarnDays)
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// currentScore returns the most recently computed HostScoreBreakdown for
+// checkURL without recording a new sample. OnHostDown/OnHostBackUp read
+// through this instead of calling recordScore themselves, since the
+// checker already drives a recordScore call for the same sample via
+// OnHostReport (every report, success or failure) before invoking the
+// transition callbacks — recording it twice would double-count that
+// sample in the rolling window.
+func (hc *HostCheckerManager) currentScore(checkURL string) HostScoreBreakdown {
+	hc.scoreMu.RLock()
+	defer hc.scoreMu.RUnlock()
+
+	if breakdown, found := hc.scores[checkURL]; found {
+		return breakdown
+	}
+	return HostScoreBreakdown{CheckURL: checkURL, Composite: 1}
+}
+
+// diagnose turns the last report plus its rolling breakdown into the
+// structured "why is this unusable" reasons attached to fired events.
+func diagnose(report HostHealthReport, breakdown HostScoreBreakdown) HostGougingBreakdown {
+	return HostGougingBreakdown{
+		TCPErr:        report.IsTCPError,
+		TLSErr:        breakdown.TLSExpiryScore < 1,
+		LatencySLOErr: breakdown.LatencyScore < 0.5,
+		StatusErr:     report.ResponseCode >= 400,
+	}
+}
+
+// HostScoreHandler implements GET /tyk/uptime/hosts/{apiID}, returning the
+// current HostScoreBreakdown for every host tracked for that API.
+func (hc *HostCheckerManager) HostScoreHandler(w http.ResponseWriter, r *http.Request) {
+	apiID := mux.Vars(r)["apiID"]
+
+	hc.scoreMu.RLock()
+	defer hc.scoreMu.RUnlock()
+	hc.ringMu.RLock()
+	defer hc.ringMu.RUnlock()
+
+	result := make([]HostScoreBreakdown, 0)
+	for checkURL, host := range hc.currentHostList {
+		if host.MetaData[UnHealthyHostMetaDataAPIKey] != apiID {
+			continue
+		}
+		if breakdown, found := hc.scores[checkURL]; found {
+			result = append(result, breakdown)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
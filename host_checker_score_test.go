@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+// allFailureWindow builds a window of n unreachable probes (TCP error, no
+// response), the dominant failure mode chunk0-3's review comment called out.
+func allFailureWindow(n int) []HostHealthReport {
+	window := make([]HostHealthReport, 0, n)
+	for i := 0; i < n; i++ {
+		window = append(window, HostHealthReport{
+			CheckURL:   "http://down.example.com",
+			IsTCPError: true,
+		})
+	}
+	return window
+}
+
+func allSuccessWindow(n int) []HostHealthReport {
+	window := make([]HostHealthReport, 0, n)
+	for i := 0; i < n; i++ {
+		window = append(window, HostHealthReport{
+			CheckURL:     "http://up.example.com",
+			ResponseCode: 200,
+			Latency:      50,
+		})
+	}
+	return window
+}
+
+func TestScoreWindowMarksUnreachableHostDown(t *testing.T) {
+	breakdown := scoreWindow("http://down.example.com", allFailureWindow(ScoreWindowSize))
+
+	if breakdown.Composite >= HostDownThreshold {
+		t.Fatalf("expected Composite below HostDownThreshold (%v) for an all-failure window, got %v", HostDownThreshold, breakdown.Composite)
+	}
+	if breakdown.ConsecutiveFailures != ScoreWindowSize {
+		t.Fatalf("expected ConsecutiveFailures == %d, got %d", ScoreWindowSize, breakdown.ConsecutiveFailures)
+	}
+}
+
+func TestScoreWindowKeepsHealthyHostUp(t *testing.T) {
+	breakdown := scoreWindow("http://up.example.com", allSuccessWindow(ScoreWindowSize))
+
+	if breakdown.Composite < HostUpThreshold {
+		t.Fatalf("expected Composite at or above HostUpThreshold (%v) for an all-success window, got %v", HostUpThreshold, breakdown.Composite)
+	}
+	if breakdown.ConsecutiveFailures != 0 {
+		t.Fatalf("expected ConsecutiveFailures == 0, got %d", breakdown.ConsecutiveFailures)
+	}
+}
+
+func TestScoreWindowEmptyWindowDefaultsToHealthy(t *testing.T) {
+	breakdown := scoreWindow("http://new.example.com", nil)
+	if breakdown.Composite != 1 {
+		t.Fatalf("expected a host with no samples yet to default to Composite == 1, got %v", breakdown.Composite)
+	}
+}
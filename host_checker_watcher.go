@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// ReloadDebounceInterval collapses a burst of filesystem events (e.g.
+	// an API definition being written in several syscalls) into a single
+	// SetCheckerHostList() call.
+	ReloadDebounceInterval = 500 * time.Millisecond
+)
+
+// GlobalReloadWatcher is the singleton started from InitHostCheckManager,
+// mirroring the GlobalHostChecker convention. The admin router (api.go)
+// should mount GlobalReloadWatcher.ReloadHandler at POST /tyk/uptime/reload
+// — that wiring lives outside this file and isn't present in this tree.
+var GlobalReloadWatcher *UptimeReloadWatcher
+
+// ReloadAPISpecs, when set, reloads API definitions from their source
+// (file, Dashboard, etc.) into ApiSpecRegister. SetCheckerHostList only
+// ever rebuilds the uptime host list from whatever is already in
+// ApiSpecRegister, so without this hook a filesystem change would be
+// picked up as "0 added, 0 removed" instead of actually refreshing the
+// check list. InitHostCheckManager defaults this to the gateway's
+// existing doReload() entrypoint; callers that want different reload
+// behaviour (e.g. in tests) can assign their own func before calling
+// InitHostCheckManager.
+var ReloadAPISpecs func() error
+
+// UptimeReloadWatcher re-runs SetCheckerHostList when the API definitions
+// directory changes, or when an operator hits the reload admin endpoint,
+// so uptime_tests.check_list changes no longer need a gateway restart.
+type UptimeReloadWatcher struct {
+	watcher      *fsnotify.Watcher
+	successCount uint64
+	failureCount uint64
+}
+
+// StartUptimeWatcher watches apiDefsDir for changes and debounces them into
+// reload calls. It returns the watcher so its Stop method can tear it down
+// again; callers that don't need a filesystem watch (e.g. tests driving the
+// reload endpoint only) can pass an empty apiDefsDir to skip it.
+func StartUptimeWatcher(apiDefsDir string) (*UptimeReloadWatcher, error) {
+	rw := &UptimeReloadWatcher{}
+
+	if apiDefsDir == "" {
+		return rw, nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(apiDefsDir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	rw.watcher = watcher
+
+	go rw.debounceLoop()
+	return rw, nil
+}
+
+func (rw *UptimeReloadWatcher) debounceLoop() {
+	var timer *time.Timer
+
+	for {
+		select {
+		case event, ok := <-rw.watcher.Events:
+			if !ok {
+				return
+			}
+			GlobalHostChecker.componentLog().WithField("path", event.Name).Debug("API definitions changed, scheduling uptime reload")
+			if timer == nil {
+				timer = time.AfterFunc(ReloadDebounceInterval, rw.reload)
+			} else {
+				timer.Reset(ReloadDebounceInterval)
+			}
+		case err, ok := <-rw.watcher.Errors:
+			if !ok {
+				return
+			}
+			GlobalHostChecker.componentLog().Error("Uptime watcher error: ", err)
+		}
+	}
+}
+
+// reload re-runs SetCheckerHostList, diffing the host list before and after
+// so the structured log line reports how many HostData entries were added
+// and removed, and tracks a running success/failure counter.
+func (rw *UptimeReloadWatcher) reload() {
+	before := GlobalHostChecker.currentHostURLSet()
+
+	err := safeSetCheckerHostList()
+
+	after := GlobalHostChecker.currentHostURLSet()
+	added, removed := diffHostSets(before, after)
+
+	if err != nil {
+		atomic.AddUint64(&rw.failureCount, 1)
+		GlobalHostChecker.componentLog().
+			WithField("event", "reload_failed").
+			WithField("added", added).
+			WithField("removed", removed).
+			Warning("Uptime reload failed: ", err)
+		return
+	}
+
+	atomic.AddUint64(&rw.successCount, 1)
+	GlobalHostChecker.componentLog().
+		WithField("event", "reload_succeeded").
+		WithField("added", added).
+		WithField("removed", removed).
+		Info("Uptime reload succeeded")
+}
+
+// safeSetCheckerHostList reloads API definitions (when ReloadAPISpecs is
+// wired up) and re-runs SetCheckerHostList, turning a panic (e.g. a
+// malformed API definition on disk mid-write) into an error so a bad
+// reload can't take the watcher loop down.
+func safeSetCheckerHostList() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = panicToErr(r)
+		}
+	}()
+
+	if ReloadAPISpecs != nil {
+		if err := ReloadAPISpecs(); err != nil {
+			return err
+		}
+	}
+
+	SetCheckerHostList()
+	return nil
+}
+
+func panicToErr(r interface{}) error {
+	return fmt.Errorf("panic during uptime reload: %v", r)
+}
+
+func diffHostSets(before, after map[string]bool) (added, removed int) {
+	for url := range after {
+		if !before[url] {
+			added++
+		}
+	}
+	for url := range before {
+		if !after[url] {
+			removed++
+		}
+	}
+	return added, removed
+}
+
+func (rw *UptimeReloadWatcher) Stop() {
+	if rw.watcher != nil {
+		rw.watcher.Close()
+	}
+}
+
+// ReloadStats is the counter snapshot returned by the admin reload endpoint.
+type ReloadStats struct {
+	Success int  `json:"success"`
+	Failure int  `json:"failure"`
+	Added   int  `json:"added"`
+	Removed int  `json:"removed"`
+	OK      bool `json:"ok"`
+}
+
+// ReloadHandler implements POST /tyk/uptime/reload: it synchronously
+// re-runs SetCheckerHostList and reports the before/after counts plus the
+// running success/failure tally.
+func (rw *UptimeReloadWatcher) ReloadHandler(w http.ResponseWriter, r *http.Request) {
+	before := GlobalHostChecker.currentHostURLSet()
+	err := safeSetCheckerHostList()
+	after := GlobalHostChecker.currentHostURLSet()
+	added, removed := diffHostSets(before, after)
+
+	stats := ReloadStats{Added: added, Removed: removed, OK: err == nil}
+
+	if err != nil {
+		atomic.AddUint64(&rw.failureCount, 1)
+		GlobalHostChecker.componentLog().WithField("event", "reload_api_failed").Warning("Uptime reload via API failed: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		atomic.AddUint64(&rw.successCount, 1)
+	}
+
+	stats.Success = int(atomic.LoadUint64(&rw.successCount))
+	stats.Failure = int(atomic.LoadUint64(&rw.failureCount))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestDiffHostSets(t *testing.T) {
+	before := map[string]bool{
+		"http://a.example.com": true,
+		"http://b.example.com": true,
+	}
+	after := map[string]bool{
+		"http://b.example.com": true,
+		"http://c.example.com": true,
+	}
+
+	added, removed := diffHostSets(before, after)
+	if added != 1 {
+		t.Fatalf("expected 1 added host, got %d", added)
+	}
+	if removed != 1 {
+		t.Fatalf("expected 1 removed host, got %d", removed)
+	}
+}
+
+func TestDiffHostSetsNoChange(t *testing.T) {
+	set := map[string]bool{"http://a.example.com": true}
+
+	added, removed := diffHostSets(set, set)
+	if added != 0 || removed != 0 {
+		t.Fatalf("expected no diff for identical sets, got added=%d removed=%d", added, removed)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// otlpMaxHostStreams bounds how many distinct (URL, APIID, OrgID)
+// MeterProviders otlpMetricCollector keeps alive at once. Without a cap, a
+// deployment with many or rotating check URLs would leak one MeterProvider
+// (and its periodic-reader goroutine) per distinct key forever.
+const otlpMaxHostStreams = 256
+
+// otlpHostStream is one host's metrics pipeline: its own MeterProvider
+// bound to a Resource carrying that host's URL/APIID/OrgID, so those
+// identify the OTLP ResourceMetrics rather than being attached per-point.
+type otlpHostStream struct {
+	provider *sdkmetric.MeterProvider
+	latency  metric.Float64Histogram
+}
+
+// otlpMetricCollector wraps an OTLP metrics pipeline so OTLPUptimeSink can
+// record one UptimeReportData at a time and export them as a batch,
+// without the rest of the sink needing to know about the OTel SDK types.
+// One otlpHostStream is kept per distinct (URL, APIID, OrgID), all sharing
+// the same exporter, since a Resource is fixed for the lifetime of a
+// MeterProvider and these three values are what the request asked to be
+// carried as resource attributes.
+type otlpMetricCollector struct {
+	exporter sdkmetric.Exporter
+
+	mu    sync.Mutex
+	hosts map[string]*otlpHostStream
+	// lru tracks keys from most- to least-recently-used (front to back),
+	// so when hosts grows past otlpMaxHostStreams the stream evicted is
+	// the one that's gone longest without a probe, not an arbitrary one.
+	lru   *list.List
+	elems map[string]*list.Element
+}
+
+func newOTLPMetricCollector(endpoint string) *otlpMetricCollector {
+	c := &otlpMetricCollector{
+		hosts: make(map[string]*otlpHostStream),
+		lru:   list.New(),
+		elems: make(map[string]*list.Element),
+	}
+
+	exporter, err := otlpmetrichttp.New(context.Background(), otlpmetrichttp.WithEndpoint(endpoint))
+	if err != nil {
+		GlobalHostChecker.componentLog().WithField("sink", "otlp").Error("Could not start OTLP uptime exporter: ", err)
+		return c
+	}
+
+	c.exporter = exporter
+	return c
+}
+
+// streamFor returns (creating if necessary) the otlpHostStream for a
+// report's (URL, APIID, OrgID), since that's the OTLP resource identity.
+func (c *otlpMetricCollector) streamFor(r UptimeReportData) *otlpHostStream {
+	key := r.URL + "|" + r.APIID + "|" + r.OrgID
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if stream, found := c.hosts[key]; found {
+		c.lru.MoveToFront(c.elems[key])
+		return stream
+	}
+	if c.exporter == nil {
+		return nil
+	}
+
+	res, err := resource.New(context.Background(), resource.WithAttributes(
+		attribute.String("url", r.URL),
+		attribute.String("api_id", r.APIID),
+		attribute.String("org_id", r.OrgID),
+	))
+	if err != nil {
+		GlobalHostChecker.componentLog().WithField("sink", "otlp").Error("Could not build OTLP resource: ", err)
+		return nil
+	}
+
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(c.exporter)),
+		sdkmetric.WithResource(res),
+	)
+
+	latency, err := provider.Meter("tyk.uptime").Float64Histogram("tyk_uptime_probe_latency_ms")
+	if err != nil {
+		GlobalHostChecker.componentLog().WithField("sink", "otlp").Error("Could not create OTLP uptime instrument: ", err)
+	}
+
+	stream := &otlpHostStream{provider: provider, latency: latency}
+	c.hosts[key] = stream
+	c.elems[key] = c.lru.PushFront(key)
+
+	c.evictLocked()
+	return stream
+}
+
+// evictLocked shuts down and drops the least-recently-used streams once
+// hosts grows past otlpMaxHostStreams. Callers must hold c.mu.
+func (c *otlpMetricCollector) evictLocked() {
+	for len(c.hosts) > otlpMaxHostStreams {
+		oldest := c.lru.Back()
+		if oldest == nil {
+			return
+		}
+		key := oldest.Value.(string)
+
+		if stream, found := c.hosts[key]; found {
+			stream.provider.Shutdown(context.Background())
+		}
+
+		c.lru.Remove(oldest)
+		delete(c.elems, key)
+		delete(c.hosts, key)
+	}
+}
+
+// RecordPoint turns a single uptime report into an OTLP metric point on the
+// stream for its host; response_code/tcp_error vary probe-to-probe for the
+// same host so they stay per-point attributes rather than resource ones.
+func (c *otlpMetricCollector) RecordPoint(r UptimeReportData) error {
+	stream := c.streamFor(r)
+	if stream == nil || stream.latency == nil {
+		return nil
+	}
+
+	stream.latency.Record(context.Background(), float64(r.RequestTime),
+		metric.WithAttributes(
+			attribute.Int("response_code", r.ResponseCode),
+			attribute.Bool("tcp_error", r.TCPError),
+		))
+	return nil
+}
+
+// Export forces every host stream's periodic reader to flush the points
+// recorded since the last export, so a batch write maps to one OTLP
+// collect/export pass per host.
+func (c *otlpMetricCollector) Export() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, stream := range c.hosts {
+		if err := stream.provider.ForceFlush(context.Background()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *otlpMetricCollector) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, stream := range c.hosts {
+		stream.provider.Shutdown(context.Background())
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// UptimeAnalyticsSink is one destination uptime analytics can be shipped to.
+// RecordUptimeAnalytics no longer writes msgpack into Redis directly; it
+// just fans UptimeReportData out to whichever sinks were configured under
+// uptime_tests.sinks.
+type UptimeAnalyticsSink interface {
+	Write(UptimeReportData) error
+	Flush()
+	Close()
+}
+
+// UptimeSinkConfig is one entry of uptime_tests.sinks in the gateway config,
+// e.g. {type: kafka, brokers: [...], topic: ...}.
+type UptimeSinkConfig struct {
+	Type     string   `json:"type"`
+	Brokers  []string `json:"brokers"`
+	Topic    string   `json:"topic"`
+	URL      string   `json:"url"`
+	Endpoint string   `json:"endpoint"`
+}
+
+const (
+	sinkQueueSize     = 10000
+	sinkBatchSize     = 200
+	sinkFlushInterval = 2 * time.Second
+)
+
+var sinkBackpressureDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "tyk_uptime_sink_dropped_total",
+	Help: "Records dropped because a sink's bounded in-memory queue was full.",
+}, []string{"sink"})
+
+var sinkQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "tyk_uptime_sink_queue_depth",
+	Help: "Current depth of a sink's bounded in-memory queue.",
+}, []string{"sink"})
+
+func init() {
+	prometheus.MustRegister(sinkBackpressureDropped, sinkQueueDepth)
+}
+
+// loadUptimeSinks builds the configured sinks, defaulting to the historical
+// single Redis sink when none are configured so upgrades without a
+// uptime_tests.sinks block keep behaving exactly as before.
+func loadUptimeSinks(store *RedisClusterStorageManager, cfgs []UptimeSinkConfig) []UptimeAnalyticsSink {
+	if len(cfgs) == 0 {
+		return []UptimeAnalyticsSink{NewRedisUptimeSink(store)}
+	}
+
+	sinks := make([]UptimeAnalyticsSink, 0, len(cfgs))
+	for _, c := range cfgs {
+		switch c.Type {
+		case "redis", "":
+			sinks = append(sinks, NewRedisUptimeSink(store))
+		case "kafka":
+			sinks = append(sinks, NewKafkaUptimeSink(c.Brokers, c.Topic))
+		case "http":
+			sinks = append(sinks, NewHTTPUptimeSink(c.URL))
+		case "otlp":
+			sinks = append(sinks, NewOTLPUptimeSink(c.Endpoint))
+		default:
+			GlobalHostChecker.componentLog().WithField("sink_type", c.Type).Warning("Unknown uptime analytics sink type")
+		}
+	}
+	return sinks
+}
+
+// batchingSink is the shared async, bounded-queue plumbing for the
+// non-Redis sinks: callers get back-pressure metrics and batching for free
+// and only need to supply writeBatch.
+type batchingSink struct {
+	name       string
+	queue      chan UptimeReportData
+	writeBatch func([]UptimeReportData) error
+	stop       chan struct{}
+	flushReq   chan chan struct{}
+}
+
+func newBatchingSink(name string, writeBatch func([]UptimeReportData) error) *batchingSink {
+	s := &batchingSink{
+		name:       name,
+		queue:      make(chan UptimeReportData, sinkQueueSize),
+		writeBatch: writeBatch,
+		stop:       make(chan struct{}),
+		flushReq:   make(chan chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+func (s *batchingSink) Write(record UptimeReportData) error {
+	select {
+	case s.queue <- record:
+		sinkQueueDepth.WithLabelValues(s.name).Set(float64(len(s.queue)))
+	default:
+		sinkBackpressureDropped.WithLabelValues(s.name).Inc()
+		GlobalHostChecker.componentLog().WithField("sink", s.name).Warning("Uptime sink queue full, dropping record")
+	}
+	return nil
+}
+
+func (s *batchingSink) loop() {
+	ticker := time.NewTicker(sinkFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]UptimeReportData, 0, sinkBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.writeBatch(batch); err != nil {
+			GlobalHostChecker.componentLog().WithField("sink", s.name).Error("Uptime sink write failed: ", err)
+		}
+		batch = batch[:0]
+	}
+	// drain pulls every record already queued into the batch, so a flush
+	// request or stop also writes records that have not been picked up yet.
+	drain := func() {
+		for {
+			select {
+			case rec := <-s.queue:
+				batch = append(batch, rec)
+				if len(batch) >= sinkBatchSize {
+					flush()
+				}
+			default:
+				sinkQueueDepth.WithLabelValues(s.name).Set(float64(len(s.queue)))
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case rec := <-s.queue:
+			batch = append(batch, rec)
+			sinkQueueDepth.WithLabelValues(s.name).Set(float64(len(s.queue)))
+			if len(batch) >= sinkBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case ack := <-s.flushReq:
+			drain()
+			flush()
+			close(ack)
+		case <-s.stop:
+			drain()
+			flush()
+			return
+		}
+	}
+}
+
+// Flush blocks until a writeBatch call driven by this request has actually
+// completed, not just until the queue has drained into the in-flight batch.
+func (s *batchingSink) Flush() {
+	ack := make(chan struct{})
+	select {
+	case s.flushReq <- ack:
+		<-ack
+	case <-s.stop:
+	}
+}
+
+func (s *batchingSink) Close() {
+	close(s.stop)
+}
+
+// RedisUptimeSink is the original behaviour: msgpack-encode the report and
+// append it to UptimeAnalytics_KEYNAME, left exactly as it was so the
+// Redis purger keeps working unchanged.
+type RedisUptimeSink struct {
+	store *RedisClusterStorageManager
+}
+
+func NewRedisUptimeSink(store *RedisClusterStorageManager) *RedisUptimeSink {
+	return &RedisUptimeSink{store: store}
+}
+
+func (s *RedisUptimeSink) Write(record UptimeReportData) error {
+	encoded, err := msgpack.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.store.AppendToSet(UptimeAnalytics_KEYNAME, string(encoded))
+	return nil
+}
+
+func (s *RedisUptimeSink) Flush() {}
+func (s *RedisUptimeSink) Close() {}
+
+// KafkaUptimeSink ships each batch of reports to a Kafka topic as
+// individually-keyed JSON messages.
+type KafkaUptimeSink struct {
+	*batchingSink
+	producer sarama.SyncProducer
+	topic    string
+}
+
+func NewKafkaUptimeSink(brokers []string, topic string) *KafkaUptimeSink {
+	cfg := sarama.NewConfig()
+	cfg.Producer.Return.Successes = true
+
+	producer, err := sarama.NewSyncProducer(brokers, cfg)
+	if err != nil {
+		GlobalHostChecker.componentLog().WithField("sink", "kafka").Error("Could not start Kafka uptime sink: ", err)
+	}
+
+	sink := &KafkaUptimeSink{producer: producer, topic: topic}
+	sink.batchingSink = newBatchingSink("kafka", sink.writeBatch)
+	return sink
+}
+
+func (s *KafkaUptimeSink) writeBatch(records []UptimeReportData) error {
+	if s.producer == nil {
+		return nil
+	}
+	for _, r := range records {
+		payload, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		_, _, err = s.producer.SendMessage(&sarama.ProducerMessage{
+			Topic: s.topic,
+			Key:   sarama.StringEncoder(r.URL),
+			Value: sarama.ByteEncoder(payload),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *KafkaUptimeSink) Close() {
+	s.batchingSink.Close()
+	if s.producer != nil {
+		s.producer.Close()
+	}
+}
+
+// HTTPUptimeSink POSTs each batch as a JSON array to a configured endpoint,
+// for operators who just want to fan uptime data into a webhook receiver.
+type HTTPUptimeSink struct {
+	*batchingSink
+	url    string
+	client *http.Client
+}
+
+func NewHTTPUptimeSink(url string) *HTTPUptimeSink {
+	sink := &HTTPUptimeSink{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+	sink.batchingSink = newBatchingSink("http", sink.writeBatch)
+	return sink
+}
+
+func (s *HTTPUptimeSink) writeBatch(records []UptimeReportData) error {
+	payload, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+// OTLPUptimeSink emits each report as an OpenTelemetry metric point, with
+// URL/APIID/OrgID attached as resource attributes, so uptime data lands in
+// whatever OTLP-speaking backend an operator already runs.
+type OTLPUptimeSink struct {
+	*batchingSink
+	endpoint  string
+	collector *otlpMetricCollector
+}
+
+func NewOTLPUptimeSink(endpoint string) *OTLPUptimeSink {
+	sink := &OTLPUptimeSink{endpoint: endpoint, collector: newOTLPMetricCollector(endpoint)}
+	sink.batchingSink = newBatchingSink("otlp", sink.writeBatch)
+	return sink
+}
+
+func (s *OTLPUptimeSink) writeBatch(records []UptimeReportData) error {
+	for _, r := range records {
+		if err := s.collector.RecordPoint(r); err != nil {
+			return err
+		}
+	}
+	return s.collector.Export()
+}
+
+func (s *OTLPUptimeSink) Close() {
+	s.batchingSink.Close()
+	s.collector.Close()
+}